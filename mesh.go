@@ -5,6 +5,8 @@ package mesh
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/gob"
 	"fmt"
 	"io"
 	"os"
@@ -16,15 +18,21 @@ import (
 // for each parser. That means if you want to parse the data in two
 // different ways (using two of the different methods that the MeSHParser
 // exposes) you will have to create a MeSHParser for each method to call.
+//
+// A read error encountered while parsing is kept on the parser and can
+// be retrieved with Err once the channel returned by one of the Parse*
+// methods has been closed.
 type MeSHParser struct {
-	meshinput   bufio.Reader
+	meshinput   *bufio.Reader
 	quotrep     *strings.Replacer
 	meshrecords MeSHRecordsMap
+	err         error
 }
 
 // MeSHTreeParser parses the MeSHTree into a tree of nodes.
 type MeSHTreeParser struct {
-	meshinput bufio.Reader
+	meshinput *bufio.Reader
+	err       error
 }
 
 type MeSHRecord struct {
@@ -46,24 +54,39 @@ func NewNode(contents map[string]*MeSHNode) *MeSHNode {
 }
 
 // NewMeSHParser returns a new MeSHParser that can be used to parse MeSH.
+// It is kept for source compatibility with callers that already built a
+// bufio.Reader of their own; new code should prefer
+// NewMeSHParserFromReader.
 func NewMeSHParser(r bufio.Reader) *MeSHParser {
-	mp := &MeSHParser{
-		meshinput:   r,
+	return &MeSHParser{
+		meshinput:   &r,
 		meshrecords: make(map[string]*MeSHRecord, 50000),
 		quotrep:     strings.NewReplacer("\"", ""),
 	}
+}
 
-	return mp
+// NewMeSHParserFromReader returns a new MeSHParser that reads from r,
+// which does not need to be a *bufio.Reader.
+func NewMeSHParserFromReader(r io.Reader) *MeSHParser {
+	return &MeSHParser{
+		meshinput:   bufio.NewReader(r),
+		meshrecords: make(map[string]*MeSHRecord, 50000),
+		quotrep:     strings.NewReplacer("\"", ""),
+	}
 }
 
 // NewMeSHTreeParser returns a new MeSHTreeParser that can be used to
-// parse the MeSH tree.
+// parse the MeSH tree. It is kept for source compatibility with callers
+// that already built a bufio.Reader of their own; new code should prefer
+// NewMeSHTreeParserFromReader.
 func NewMeSHTreeParser(r bufio.Reader) *MeSHTreeParser {
-	mtp := &MeSHTreeParser{
-		meshinput: r,
-	}
+	return &MeSHTreeParser{meshinput: &r}
+}
 
-	return mtp
+// NewMeSHTreeParserFromReader returns a new MeSHTreeParser that reads
+// from r, which does not need to be a *bufio.Reader.
+func NewMeSHTreeParserFromReader(r io.Reader) *MeSHTreeParser {
+	return &MeSHTreeParser{meshinput: bufio.NewReader(r)}
 }
 
 func (mn *MeSHNode) Add(nodepath []string) {
@@ -90,55 +113,245 @@ func (mn *MeSHNode) GetDict() map[string]*MeSHNode {
 	return mn.cont
 }
 
+// GobEncode lets a MeSHNode be used with encoding/gob despite cont being
+// unexported, which is how WriteCache persists the tree.
+func (mn *MeSHNode) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(mn.cont); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode is the counterpart of GobEncode.
+func (mn *MeSHNode) GobDecode(data []byte) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(&mn.cont)
+}
+
+// navigate walks down from mn following path, one component per entry,
+// and returns the node found there, or nil if path does not exist.
+func (mn *MeSHNode) navigate(path []string) *MeSHNode {
+	nn := mn
+	for _, s := range path {
+		curn, ok := nn.cont[s]
+		if !ok {
+			return nil
+		}
+		nn = curn
+	}
+	return nn
+}
+
+// GetSamePrefix returns the full paths of every descendant of prefix. It
+// is kept for source compatibility; new code should call GetDescendants.
 func (mn *MeSHNode) GetSamePrefix(prefix string) []string {
-	var (
-		curn, nn *MeSHNode
-		ok       bool
-		finres   []string
-		path     string
-	)
-	splitpre := strings.Split(prefix, ".")
+	return mn.GetDescendants(prefix)
+}
 
-	nn = mn
-	for _, s := range splitpre {
-		if curn, ok = nn.cont[s]; !ok {
+// GetDescendants returns the full paths of every descendant of path, in
+// no particular order. It returns nil if path does not exist.
+func (mn *MeSHNode) GetDescendants(path string) []string {
+	nn := mn.navigate(strings.Split(path, "."))
+	if nn == nil {
+		return nil
+	}
+
+	var res []string
+	stack := append([]string{}, strings.Split(path, ".")...)
+	collectDescendants(nn, stack, &res)
+	return res
+}
+
+// collectDescendants appends the full path of every descendant of mn to
+// *res, reusing stack as scratch space and only joining it into a string
+// once a path is about to be returned to the caller.
+func collectDescendants(mn *MeSHNode, stack []string, res *[]string) {
+	for k, nn := range mn.cont {
+		stack = append(stack, k)
+		*res = append(*res, strings.Join(stack, "."))
+		collectDescendants(nn, stack, res)
+		stack = stack[:len(stack)-1]
+	}
+}
+
+// GetChildren returns the full paths of the direct children of path. It
+// returns nil if path does not exist.
+func (mn *MeSHNode) GetChildren(path string) []string {
+	nn := mn.navigate(strings.Split(path, "."))
+	if nn == nil {
+		return nil
+	}
+
+	children := make([]string, 0, len(nn.cont))
+	for k := range nn.cont {
+		children = append(children, path+"."+k)
+	}
+	return children
+}
+
+// GetAncestors returns the full paths of every ancestor of path, ordered
+// from the root down to the immediate parent. It returns nil if path
+// does not exist.
+func (mn *MeSHNode) GetAncestors(path string) []string {
+	parts := strings.Split(path, ".")
+
+	var ancestors []string
+	nn := mn
+	for i, s := range parts {
+		curn, ok := nn.cont[s]
+		if !ok {
 			return nil
+		}
+		if i > 0 {
+			ancestors = append(ancestors, strings.Join(parts[:i], "."))
+		}
+		nn = curn
+	}
+	return ancestors
+}
+
+// GetSiblings returns the full paths of every other node sharing path's
+// parent. It returns nil if path does not exist.
+func (mn *MeSHNode) GetSiblings(path string) []string {
+	parts := strings.Split(path, ".")
+
+	parent := mn.navigate(parts[:len(parts)-1])
+	if parent == nil {
+		return nil
+	}
+	self := parts[len(parts)-1]
+	if _, ok := parent.cont[self]; !ok {
+		return nil
+	}
+
+	prefix := strings.Join(parts[:len(parts)-1], ".")
+	siblings := make([]string, 0, len(parent.cont)-1)
+	for k := range parent.cont {
+		if k == self {
+			continue
+		}
+		if prefix == "" {
+			siblings = append(siblings, k)
 		} else {
-			path += s + "."
-			nn = curn
+			siblings = append(siblings, prefix+"."+k)
 		}
 	}
-	for k, _ := range nn.cont {
-		var res []string
+	return siblings
+}
 
-		curpath := path + k
-		partres := getsuffices(curpath, res, nn.cont[k])
-		finres = append(finres, append(partres, curpath)...)
-		//fmt.Fprintf(os.Stderr, "GetSamePrefix curpath: %s partres: %#v, res: %#v\n", curpath, partres, res)
+// Depth returns the number of components in path, or -1 if path does not
+// exist in the tree.
+func (mn *MeSHNode) Depth(path string) int {
+	parts := strings.Split(path, ".")
+	if mn.navigate(parts) == nil {
+		return -1
 	}
-	return finres
+	return len(parts)
 }
 
-func getsuffices(path string, res []string, mn *MeSHNode) []string {
+// Walk visits every node in the tree in depth-first, tree order, calling
+// fn with its full path. It stops and returns the first error fn
+// returns.
+func (mn *MeSHNode) Walk(fn func(path string) error) error {
+	return walk(mn, nil, fn)
+}
+
+func walk(mn *MeSHNode, stack []string, fn func(path string) error) error {
 	for k, nn := range mn.cont {
-		curpath := path + "." + k
-		res = getsuffices(curpath, res, nn)
-		res = append(res, curpath)
+		stack = append(stack, k)
+		if err := fn(strings.Join(stack, ".")); err != nil {
+			return err
+		}
+		if err := walk(nn, stack, fn); err != nil {
+			return err
+		}
+		stack = stack[:len(stack)-1]
 	}
+	return nil
+}
 
-	return res
+// MeSH combines a MeSH tree with the records map produced while parsing
+// it, so that callers can move between the two: from a tree number to
+// its record, or from a record's UI or heading back to every tree number
+// it appears at.
+type MeSH struct {
+	Tree    *MeSHNode
+	Records MeSHRecordsMap
+
+	byUI map[string]*MeSHRecord
+	byMH map[string]*MeSHRecord
 }
 
+// NewMeSH returns a MeSH combining tree and records, indexing records by
+// UI and heading for TreeNumbersForUI and TreeNumbersForMH.
+func NewMeSH(tree *MeSHNode, records MeSHRecordsMap) *MeSH {
+	byUI := make(map[string]*MeSHRecord, len(records))
+	byMH := make(map[string]*MeSHRecord, len(records))
+	seen := make(map[*MeSHRecord]bool, len(records))
+	for _, record := range records {
+		if seen[record] {
+			continue
+		}
+		seen[record] = true
+		byUI[record.UI] = record
+		byMH[record.MH] = record
+	}
+
+	return &MeSH{Tree: tree, Records: records, byUI: byUI, byMH: byMH}
+}
+
+// TreeNumbersForUI returns every tree number the record with the given UI
+// appears at, or nil if ui is not known.
+func (m *MeSH) TreeNumbersForUI(ui string) []string {
+	if record, ok := m.byUI[ui]; ok {
+		return record.MN
+	}
+	return nil
+}
+
+// TreeNumbersForMH returns every tree number the record with the given
+// heading appears at, or nil if mh is not known.
+func (m *MeSH) TreeNumbersForMH(mh string) []string {
+	if record, ok := m.byMH[mh]; ok {
+		return record.MN
+	}
+	return nil
+}
+
+// ParseMeSHTree reads the MeSH tree file and adds every node it finds to
+// meshnode. It is kept for source compatibility and parses with
+// context.Background(); new code should prefer ParseMeSHTreeContext and
+// check its returned error directly instead of calling Err afterwards.
 func (mtp *MeSHTreeParser) ParseMeSHTree(meshnode MeSHNode) {
+	mtp.err = mtp.ParseMeSHTreeContext(context.Background(), &meshnode)
+}
+
+// Err returns the error encountered by ParseMeSHTree, once it has
+// returned. It returns nil if parsing completed successfully or hasn't
+// run yet.
+func (mtp *MeSHTreeParser) Err() error {
+	return mtp.err
+}
+
+// ParseMeSHTreeContext reads the MeSH tree file and adds every node it
+// finds to meshnode. It stops and returns ctx.Err() if ctx is cancelled
+// before parsing completes, and any other error is returned wrapped with
+// the line number it occurred at.
+func (mtp *MeSHTreeParser) ParseMeSHTreeContext(ctx context.Context, meshnode *MeSHNode) error {
 	lineno := 0
 	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
 		line, err := mtp.meshinput.ReadString('\n')
 		if err != nil {
 			if err == io.EOF {
-				break
+				return nil
 			}
-			fmt.Printf("Error while reading MeSH tree file at line nr. %d: %v\n", lineno, err)
-			os.Exit(1)
+			return fmt.Errorf("error while reading MeSH tree file at line nr. %d: %w", lineno, err)
 		}
 
 		if line == "\n" {
@@ -147,7 +360,7 @@ func (mtp *MeSHTreeParser) ParseMeSHTree(meshnode MeSHNode) {
 
 		splitl := strings.Split(line, ";")
 		if len(splitl) < 2 {
-			fmt.Printf("Error while reading MeSH tree file at line nr. %d. Split did not result in two values.\n", lineno)
+			return fmt.Errorf("error while reading MeSH tree file at line nr. %d: split did not result in two values", lineno)
 		}
 		trimmednodeid := strings.Trim(splitl[1], " \n")
 		splitpath := strings.Split(trimmednodeid, ".")
@@ -155,7 +368,14 @@ func (mtp *MeSHTreeParser) ParseMeSHTree(meshnode MeSHNode) {
 	}
 }
 
-func (mp *MeSHParser) parseMeSH(meshchan chan *MeSHRecord) {
+// Err returns the first error encountered while parsing, once the
+// channel returned by one of the Parse* methods has been closed. It
+// returns nil if parsing completed successfully or hasn't finished yet.
+func (mp *MeSHParser) Err() error {
+	return mp.err
+}
+
+func (mp *MeSHParser) parseMeSH(ctx context.Context, meshchan chan *MeSHRecord) {
 	var (
 		record         *MeSHRecord
 		recordsstarted bool
@@ -168,13 +388,20 @@ func (mp *MeSHParser) parseMeSH(meshchan chan *MeSHRecord) {
 	lineno := 0
 
 	for {
+		select {
+		case <-ctx.Done():
+			mp.err = ctx.Err()
+			return
+		default:
+		}
+
 		line, err := mp.meshinput.ReadString('\n')
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
-			fmt.Printf("Error while reading obo file at line nr. %d: %v\n", lineno, err)
-			os.Exit(1)
+			mp.err = fmt.Errorf("error while reading obo file at line nr. %d: %w", lineno, err)
+			return
 		}
 		lineno++
 		line = line[:len(line)-1] // chop \n
@@ -221,9 +448,11 @@ func (mp *MeSHParser) parseMeSH(meshchan chan *MeSHRecord) {
 		prevField = strings.Trim(splitline[0], " ")
 	}
 
-	mp.writeRecordField(record, prevField, fieldBuffer)
-	fieldBuffer.Reset()
-	meshchan <- record
+	if record != nil {
+		mp.writeRecordField(record, prevField, fieldBuffer)
+		fieldBuffer.Reset()
+		meshchan <- record
+	}
 }
 
 func (mp *MeSHParser) writeRecordField(record *MeSHRecord, fieldName string, buf bytes.Buffer) {
@@ -240,22 +469,38 @@ func (mp *MeSHParser) writeRecordField(record *MeSHRecord, fieldName string, buf
 		mp.meshrecords[value] = record
 	case "ENTRY", "PRINT ENTRY":
 		synline := strings.SplitN(value, "|", 2)
-		synstr := synline[0]
-		if strings.Contains(synstr, ", ") {
-			parts := strings.SplitN(synstr, ", ", 2)
-			synstr = parts[1] + " " + parts[0]
-		}
-		record.Entries[mp.quotrep.Replace(synstr)] = true
+		record.Entries[mp.quotrep.Replace(reorderPersonalName(synline[0]))] = true
+	}
+}
+
+// reorderPersonalName turns a "lastname, firstname" heading into
+// "firstname lastname", which is how MeSH entry terms are meant to be
+// read. Headings without a comma are returned unchanged.
+func reorderPersonalName(s string) string {
+	if strings.Contains(s, ", ") {
+		parts := strings.SplitN(s, ", ", 2)
+		return parts[1] + " " + parts[0]
 	}
+	return s
 }
 
 // Parses a MeSH into a slice of MeSHRecords and also fills a map to the
-// records and returns it.
+// records and returns it. Call Err after it returns to check whether
+// parsing completed successfully. It is kept for source compatibility
+// and parses with context.Background(); new code should prefer
+// ParseToSliceAndMapContext.
 func (mp *MeSHParser) ParseToSliceAndMap() ([]*MeSHRecord, MeSHRecordsMap) {
+	return mp.ParseToSliceAndMapContext(context.Background())
+}
+
+// ParseToSliceAndMapContext parses a MeSH into a slice of MeSHRecords and
+// also fills a map to the records and returns it. Call Err after it
+// returns to check whether parsing completed successfully.
+func (mp *MeSHParser) ParseToSliceAndMapContext(ctx context.Context) ([]*MeSHRecord, MeSHRecordsMap) {
 	meshchan := make(chan *MeSHRecord, 1000)
 	mrslice := make([]*MeSHRecord, 0, 50000)
 
-	go mp.parseMeSH(meshchan)
+	go mp.parseMeSH(ctx, meshchan)
 	for mr := range meshchan {
 		mrslice = append(mrslice, mr)
 	}
@@ -264,9 +509,19 @@ func (mp *MeSHParser) ParseToSliceAndMap() ([]*MeSHRecord, MeSHRecordsMap) {
 }
 
 // This function returns a channel on which pointers to the parsed
-// MeSHRecords will be sent.
+// MeSHRecords will be sent. Call Err once the channel is closed to check
+// whether parsing completed successfully. It is kept for source
+// compatibility and parses with context.Background(); new code should
+// prefer ParseToChannelContext.
 func (mp *MeSHParser) ParseToChannel(meshchan chan *MeSHRecord) chan *MeSHRecord {
-	go mp.parseMeSH(meshchan)
+	return mp.ParseToChannelContext(context.Background(), meshchan)
+}
+
+// ParseToChannelContext returns a channel on which pointers to the
+// parsed MeSHRecords will be sent. Call Err once the channel is closed to
+// check whether parsing completed successfully.
+func (mp *MeSHParser) ParseToChannelContext(ctx context.Context, meshchan chan *MeSHRecord) chan *MeSHRecord {
+	go mp.parseMeSH(ctx, meshchan)
 
 	return meshchan
 }
@@ -274,10 +529,22 @@ func (mp *MeSHParser) ParseToChannel(meshchan chan *MeSHRecord) chan *MeSHRecord
 // This function returns a channel on which pointers to the parsed
 // MeSHRecords will be sent. We also return the map to the MeSHRecords
 // which can only be used after the channel has been closed (because
-// this indicates that the parsing has been completed).
+// this indicates that the parsing has been completed). Call Err once the
+// channel is closed to check whether parsing completed successfully. It
+// is kept for source compatibility and parses with context.Background();
+// new code should prefer ParseToChannelAndMapContext.
 func (mp *MeSHParser) ParseToChannelAndMap(meshchan chan *MeSHRecord) (chan *MeSHRecord, MeSHRecordsMap) {
+	return mp.ParseToChannelAndMapContext(context.Background(), meshchan)
+}
 
-	go mp.parseMeSH(meshchan)
+// ParseToChannelAndMapContext returns a channel on which pointers to the
+// parsed MeSHRecords will be sent. We also return the map to the
+// MeSHRecords which can only be used after the channel has been closed
+// (because this indicates that the parsing has been completed). Call Err
+// once the channel is closed to check whether parsing completed
+// successfully.
+func (mp *MeSHParser) ParseToChannelAndMapContext(ctx context.Context, meshchan chan *MeSHRecord) (chan *MeSHRecord, MeSHRecordsMap) {
+	go mp.parseMeSH(ctx, meshchan)
 
 	return meshchan, mp.meshrecords
 }
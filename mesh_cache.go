@@ -0,0 +1,165 @@
+/* See LICENSE file for copyright and license details. */
+
+package mesh
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+)
+
+// cacheFormatVersion is bumped whenever the gob payload written by
+// WriteCache changes shape in a way that ReadCache can no longer decode.
+const cacheFormatVersion byte = 1
+
+type recordsCachePayload struct {
+	Year    string
+	Records MeSHRecordsMap
+}
+
+// WriteCache writes m to w in a compact binary format that ReadCache can
+// later load in a fraction of the time a full ASCII parse takes. year
+// identifies the MeSH release the records came from and is stored in the
+// cache header so a caller can sanity check it on load.
+func (m MeSHRecordsMap) WriteCache(w io.Writer, year string) error {
+	if _, err := w.Write([]byte{cacheFormatVersion}); err != nil {
+		return fmt.Errorf("writing MeSH cache header: %w", err)
+	}
+	if err := gob.NewEncoder(w).Encode(recordsCachePayload{Year: year, Records: m}); err != nil {
+		return fmt.Errorf("writing MeSH cache body: %w", err)
+	}
+	return nil
+}
+
+// ReadCache reads a cache written by MeSHRecordsMap.WriteCache and
+// returns the records together with the MeSH year stored alongside them.
+func ReadCache(r io.Reader) (MeSHRecordsMap, string, error) {
+	var version [1]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
+		return nil, "", fmt.Errorf("reading MeSH cache header: %w", err)
+	}
+	if version[0] != cacheFormatVersion {
+		return nil, "", fmt.Errorf("unsupported MeSH cache format version %d", version[0])
+	}
+
+	var payload recordsCachePayload
+	if err := gob.NewDecoder(r).Decode(&payload); err != nil {
+		return nil, "", fmt.Errorf("reading MeSH cache body: %w", err)
+	}
+	return payload.Records, payload.Year, nil
+}
+
+type treeCachePayload struct {
+	Year string
+	Tree *MeSHNode
+}
+
+// WriteCache writes mn to w in the same binary format MeSHRecordsMap
+// uses, so a parsed MeSH tree can be reloaded with ReadTreeCache instead
+// of being rebuilt from the tree file.
+func (mn *MeSHNode) WriteCache(w io.Writer, year string) error {
+	if _, err := w.Write([]byte{cacheFormatVersion}); err != nil {
+		return fmt.Errorf("writing MeSH tree cache header: %w", err)
+	}
+	if err := gob.NewEncoder(w).Encode(treeCachePayload{Year: year, Tree: mn}); err != nil {
+		return fmt.Errorf("writing MeSH tree cache body: %w", err)
+	}
+	return nil
+}
+
+// ReadTreeCache reads a cache written by MeSHNode.WriteCache and returns
+// the tree together with the MeSH year stored alongside it.
+func ReadTreeCache(r io.Reader) (*MeSHNode, string, error) {
+	var version [1]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
+		return nil, "", fmt.Errorf("reading MeSH tree cache header: %w", err)
+	}
+	if version[0] != cacheFormatVersion {
+		return nil, "", fmt.Errorf("unsupported MeSH tree cache format version %d", version[0])
+	}
+
+	payload := treeCachePayload{Tree: NewNode(make(map[string]*MeSHNode, 5))}
+	if err := gob.NewDecoder(r).Decode(&payload); err != nil {
+		return nil, "", fmt.Errorf("reading MeSH tree cache body: %w", err)
+	}
+	return payload.Tree, payload.Year, nil
+}
+
+// Load parses the MeSH ASCII record dump at path, the way
+// MeSHParser.ParseToSliceAndMap does, except that it transparently
+// prefers a ".cache" sidecar next to path when that sidecar is newer
+// than path itself and was written for the same year. A freshly parsed
+// file is written back to the sidecar, tagged with year, on a
+// best-effort basis so the next Load call is fast.
+func Load(ctx context.Context, path, year string) ([]*MeSHRecord, MeSHRecordsMap, error) {
+	if mrslice, mrmap, ok := loadFromCache(path, year); ok {
+		return mrslice, mrmap, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	mp := NewMeSHParserFromReader(f)
+	mrslice, mrmap := mp.ParseToSliceAndMapContext(ctx)
+	if err := mp.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	writeCacheSidecar(path, year, mrmap)
+
+	return mrslice, mrmap, nil
+}
+
+func loadFromCache(path, year string) ([]*MeSHRecord, MeSHRecordsMap, bool) {
+	srcInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	cf, err := os.Open(path + ".cache")
+	if err != nil {
+		return nil, nil, false
+	}
+	defer cf.Close()
+
+	cacheInfo, err := cf.Stat()
+	if err != nil || !cacheInfo.ModTime().After(srcInfo.ModTime()) {
+		return nil, nil, false
+	}
+
+	mrmap, cacheYear, err := ReadCache(cf)
+	if err != nil || cacheYear != year {
+		return nil, nil, false
+	}
+
+	// gob does not preserve pointer identity across a Decode, so the
+	// records in mrmap that share a MeSHRecord (one per MN they carry)
+	// come back as distinct values. Dedup on UI, which does survive the
+	// round trip, rather than on pointer identity.
+	seen := make(map[string]bool, len(mrmap))
+	mrslice := make([]*MeSHRecord, 0, len(mrmap))
+	for _, record := range mrmap {
+		if seen[record.UI] {
+			continue
+		}
+		seen[record.UI] = true
+		mrslice = append(mrslice, record)
+	}
+
+	return mrslice, mrmap, true
+}
+
+func writeCacheSidecar(path, year string, mrmap MeSHRecordsMap) {
+	cf, err := os.Create(path + ".cache")
+	if err != nil {
+		return
+	}
+	defer cf.Close()
+
+	mrmap.WriteCache(cf, year)
+}
@@ -0,0 +1,93 @@
+/* See LICENSE file for copyright and license details. */
+
+package mesh
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMeSHRecordsMapCacheRoundTrip(t *testing.T) {
+	bar := &MeSHRecord{UI: "D000002", MH: "Disease Bar", MN: []string{"C02.001", "C02.002"}, Entries: map[string]bool{"Bar Disease": true}}
+	records := MeSHRecordsMap{
+		"C01.001": {UI: "D000001", MH: "Disease Foo", MN: []string{"C01.001"}, Entries: map[string]bool{"Foo Disease": true}},
+		"C02.001": bar,
+		"C02.002": bar,
+	}
+
+	var buf bytes.Buffer
+	if err := records.WriteCache(&buf, "2024"); err != nil {
+		t.Fatalf("WriteCache: %v", err)
+	}
+
+	got, year, err := ReadCache(&buf)
+	if err != nil {
+		t.Fatalf("ReadCache: %v", err)
+	}
+	if year != "2024" {
+		t.Errorf("year = %q, want %q", year, "2024")
+	}
+	if len(got) != len(records) {
+		t.Fatalf("got %d records, want %d", len(got), len(records))
+	}
+	for mn, want := range records {
+		record, ok := got[mn]
+		if !ok {
+			t.Fatalf("got is missing record for tree number %q", mn)
+		}
+		if record.UI != want.UI || record.MH != want.MH {
+			t.Errorf("got[%q] = %+v, want UI %q MH %q", mn, record, want.UI, want.MH)
+		}
+		for entry := range want.Entries {
+			if !record.Entries[entry] {
+				t.Errorf("got[%q].Entries = %v, want it to contain %q", mn, record.Entries, entry)
+			}
+		}
+	}
+}
+
+func TestReadCacheRejectsWrongVersion(t *testing.T) {
+	buf := bytes.NewBuffer([]byte{cacheFormatVersion + 1})
+	if _, _, err := ReadCache(buf); err == nil {
+		t.Fatal("ReadCache: got nil error for an unsupported cache format version, want an error")
+	}
+}
+
+func TestMeSHNodeCacheRoundTrip(t *testing.T) {
+	tree := buildTestTree()
+
+	var buf bytes.Buffer
+	if err := tree.WriteCache(&buf, "2024"); err != nil {
+		t.Fatalf("WriteCache: %v", err)
+	}
+
+	got, year, err := ReadTreeCache(&buf)
+	if err != nil {
+		t.Fatalf("ReadTreeCache: %v", err)
+	}
+	if year != "2024" {
+		t.Errorf("year = %q, want %q", year, "2024")
+	}
+
+	want := tree.GetDescendants("C01")
+	gotDescendants := got.GetDescendants("C01")
+	if len(gotDescendants) != len(want) {
+		t.Fatalf("GetDescendants(%q) after round trip = %v, want %v", "C01", gotDescendants, want)
+	}
+	seen := make(map[string]bool, len(want))
+	for _, p := range want {
+		seen[p] = true
+	}
+	for _, p := range gotDescendants {
+		if !seen[p] {
+			t.Errorf("GetDescendants(%q) after round trip has unexpected path %q", "C01", p)
+		}
+	}
+}
+
+func TestReadTreeCacheRejectsWrongVersion(t *testing.T) {
+	buf := bytes.NewBuffer([]byte{cacheFormatVersion + 1})
+	if _, _, err := ReadTreeCache(buf); err == nil {
+		t.Fatal("ReadTreeCache: got nil error for an unsupported cache format version, want an error")
+	}
+}
@@ -0,0 +1,264 @@
+/* See LICENSE file for copyright and license details. */
+
+package mesh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+)
+
+// newRecordMarker delimits records in the MeSH ASCII dump; records never
+// span it, which is what makes ParseParallel possible.
+var newRecordMarker = []byte("*NEWRECORD")
+
+// ParseParallelOption configures the behaviour of ParseParallel.
+type ParseParallelOption func(*parallelOptions)
+
+type parallelOptions struct {
+	ordered bool
+}
+
+// WithOrdered makes ParseParallel emit records on meshchan in the same
+// order they appear in the source file. Without it, records are emitted
+// as soon as whichever worker parsed them finishes, which is faster but
+// unordered; most consumers don't care about order.
+func WithOrdered() ParseParallelOption {
+	return func(o *parallelOptions) { o.ordered = true }
+}
+
+type parallelJob struct {
+	data []byte
+	out  chan *MeSHRecord
+}
+
+// ParseParallel is an opt-in, multi-core alternative to ParseToChannel.
+// It reads the whole input once, slices it into one zero-copy []byte per
+// record at the *NEWRECORD boundaries, and fans those slices out to n
+// worker goroutines that each run the same field-parsing state machine
+// as the single-threaded path over a single record. Call Err once
+// meshchan has been closed to check whether parsing completed
+// successfully.
+func (mp *MeSHParser) ParseParallel(ctx context.Context, n int, opts ...ParseParallelOption) chan *MeSHRecord {
+	var o parallelOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	meshchan := make(chan *MeSHRecord, 1000)
+	go mp.parseMeSHParallel(ctx, n, o, meshchan)
+
+	return meshchan
+}
+
+func (mp *MeSHParser) parseMeSHParallel(ctx context.Context, n int, o parallelOptions, meshchan chan *MeSHRecord) {
+	defer close(meshchan)
+
+	var mu sync.Mutex
+
+	jobs := make(chan parallelJob, n*2)
+	var order chan chan *MeSHRecord
+	var orderDone chan struct{}
+	if o.ordered {
+		order = make(chan chan *MeSHRecord, n*2)
+		orderDone = make(chan struct{})
+		go mp.emitOrdered(ctx, order, orderDone, meshchan)
+	}
+
+	var workers sync.WaitGroup
+	workers.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer workers.Done()
+			mp.worker(ctx, &mu, jobs, meshchan)
+		}()
+	}
+
+	if err := mp.splitRecords(ctx, jobs, order); err != nil {
+		mu.Lock()
+		if mp.err == nil {
+			mp.err = err
+		}
+		mu.Unlock()
+	}
+
+	if order != nil {
+		close(order)
+		<-orderDone
+	}
+	close(jobs)
+	workers.Wait()
+}
+
+func (mp *MeSHParser) worker(ctx context.Context, mu *sync.Mutex, jobs <-chan parallelJob, meshchan chan *MeSHRecord) {
+	for j := range jobs {
+		record, err := mp.parseRecordBytes(mu, j.data)
+		if err != nil {
+			mu.Lock()
+			if mp.err == nil {
+				mp.err = err
+			}
+			mu.Unlock()
+			if j.out != nil {
+				close(j.out)
+			}
+			continue
+		}
+
+		if j.out != nil {
+			j.out <- record
+			continue
+		}
+
+		select {
+		case meshchan <- record:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// emitOrdered forwards records to meshchan in the order their source
+// channels were handed to it, regardless of which worker finishes first.
+func (mp *MeSHParser) emitOrdered(ctx context.Context, order <-chan chan *MeSHRecord, done chan<- struct{}, meshchan chan *MeSHRecord) {
+	defer close(done)
+
+	for {
+		var out chan *MeSHRecord
+		var ok bool
+		select {
+		case out, ok = <-order:
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case record, ok := <-out:
+			if !ok {
+				continue
+			}
+			select {
+			case meshchan <- record:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// splitRecords reads all of mp.meshinput and sends one job per record
+// found at the *NEWRECORD boundaries. Each job's data is a subslice of
+// the single buffer read from the input, so no per-record copy happens
+// here. When order is non-nil, a matching output channel is handed to it
+// before the job is queued, so emitOrdered can wait on it in the right
+// sequence.
+func (mp *MeSHParser) splitRecords(ctx context.Context, jobs chan<- parallelJob, order chan<- chan *MeSHRecord) error {
+	data, err := io.ReadAll(mp.meshinput)
+	if err != nil {
+		return err
+	}
+
+	chunks := bytes.Split(data, newRecordMarker)
+	if len(chunks) > 0 {
+		chunks = chunks[1:] // everything before the first *NEWRECORD is preamble, never a record
+	}
+
+	for _, chunk := range chunks {
+		if len(bytes.TrimSpace(chunk)) == 0 {
+			continue
+		}
+
+		var out chan *MeSHRecord
+		if order != nil {
+			out = make(chan *MeSHRecord, 1)
+			select {
+			case order <- out:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		select {
+		case jobs <- parallelJob{data: chunk, out: out}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// parseRecordBytes runs the same field-parsing state machine as
+// parseMeSH, but over a single already-delimited record. Once the record
+// is fully parsed, mp.meshrecords is updated for every tree number it
+// carries under mu, which is the only state parseRecordBytes shares with
+// its siblings running in other worker goroutines.
+func (mp *MeSHParser) parseRecordBytes(mu *sync.Mutex, data []byte) (*MeSHRecord, error) {
+	record := &MeSHRecord{Entries: make(map[string]bool, 5)}
+
+	var (
+		prevField   string
+		fieldBuffer bytes.Buffer
+	)
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || line[0] == '!' {
+			continue
+		}
+
+		splitline := strings.SplitN(line, " = ", 2)
+		if len(splitline) < 2 {
+			fieldBuffer.WriteString(strings.TrimSpace(splitline[0]))
+			continue
+		}
+
+		if fieldBuffer.Len() > 0 {
+			mp.setRecordField(record, prevField, fieldBuffer)
+			fieldBuffer.Reset()
+		}
+		fieldBuffer.WriteString(strings.TrimSpace(splitline[1]))
+		prevField = strings.Trim(splitline[0], " ")
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	mp.setRecordField(record, prevField, fieldBuffer)
+
+	mu.Lock()
+	for _, mn := range record.MN {
+		mp.meshrecords[mn] = record
+	}
+	mu.Unlock()
+
+	return record, nil
+}
+
+// setRecordField is the record-local half of writeRecordField: it fills
+// in record's fields but, unlike writeRecordField, never touches
+// mp.meshrecords, so it is safe to call from multiple worker goroutines
+// at once without synchronization.
+func (mp *MeSHParser) setRecordField(record *MeSHRecord, fieldName string, buf bytes.Buffer) {
+	value := buf.String()
+	switch fieldName {
+	case "UI":
+		record.UI = value
+	case "MH":
+		record.MH = value
+	case "MS":
+		record.MS = value
+	case "MN":
+		record.MN = append(record.MN, value)
+	case "ENTRY", "PRINT ENTRY":
+		synline := strings.SplitN(value, "|", 2)
+		record.Entries[mp.quotrep.Replace(reorderPersonalName(synline[0]))] = true
+	}
+}
@@ -0,0 +1,126 @@
+/* See LICENSE file for copyright and license details. */
+
+package mesh
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// buildASCIIRecords synthesizes n well-formed MeSH ASCII records, each
+// with two MN tree numbers and one ENTRY, for use as parser test input.
+func buildASCIIRecords(n int) string {
+	var b strings.Builder
+	b.WriteString("header line before the first record, never part of any record\n")
+	for i := 0; i < n; i++ {
+		s := strconv.Itoa(i)
+		b.WriteString("*NEWRECORD\n")
+		b.WriteString("MH = Disease " + s + "\n")
+		b.WriteString("UI = D" + s + "\n")
+		b.WriteString("MN = C01." + s + "\n")
+		b.WriteString("MN = C02." + s + "\n")
+		b.WriteString("ENTRY = Foo" + s + ", Bar" + s + "|T123\n")
+	}
+	return b.String()
+}
+
+// TestParseParallelMatchesSerial guards against the worker pool or the
+// emitOrdered rendezvous dropping, duplicating or reordering records
+// relative to the single-threaded parser.
+func TestParseParallelMatchesSerial(t *testing.T) {
+	data := buildASCIIRecords(500)
+
+	serial := NewMeSHParserFromReader(strings.NewReader(data))
+	serialSlice, _ := serial.ParseToSliceAndMap()
+	if err := serial.Err(); err != nil {
+		t.Fatalf("serial parse: %v", err)
+	}
+
+	ordered := NewMeSHParserFromReader(strings.NewReader(data))
+	orderedChan := ordered.ParseParallel(context.Background(), 8, WithOrdered())
+	var orderedSlice []*MeSHRecord
+	for r := range orderedChan {
+		orderedSlice = append(orderedSlice, r)
+	}
+	if err := ordered.Err(); err != nil {
+		t.Fatalf("ordered ParseParallel: %v", err)
+	}
+	if len(orderedSlice) != len(serialSlice) {
+		t.Fatalf("ordered ParseParallel returned %d records, serial returned %d", len(orderedSlice), len(serialSlice))
+	}
+	for i := range serialSlice {
+		if orderedSlice[i].UI != serialSlice[i].UI {
+			t.Fatalf("record %d: ordered ParseParallel UI %q, serial UI %q", i, orderedSlice[i].UI, serialSlice[i].UI)
+		}
+	}
+
+	unordered := NewMeSHParserFromReader(strings.NewReader(data))
+	unorderedChan := unordered.ParseParallel(context.Background(), 8)
+	seen := make(map[string]bool, len(serialSlice))
+	count := 0
+	for r := range unorderedChan {
+		count++
+		seen[r.UI] = true
+	}
+	if err := unordered.Err(); err != nil {
+		t.Fatalf("unordered ParseParallel: %v", err)
+	}
+	if count != len(serialSlice) {
+		t.Fatalf("unordered ParseParallel returned %d records, serial returned %d", count, len(serialSlice))
+	}
+	for _, r := range serialSlice {
+		if !seen[r.UI] {
+			t.Fatalf("unordered ParseParallel is missing record %q", r.UI)
+		}
+	}
+}
+
+// BenchmarkParseParallel measures how ParseParallel scales with worker
+// count against the single-threaded parser. Point MESH_DESC_FILE at a
+// real MeSH ASCII descriptor dump (e.g. d2024.bin) to run it; it's
+// skipped otherwise since that file isn't something we can ship.
+func BenchmarkParseParallel(b *testing.B) {
+	path := os.Getenv("MESH_DESC_FILE")
+	if path == "" {
+		b.Skip("set MESH_DESC_FILE to a MeSH ASCII descriptor dump to run this benchmark")
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			f, err := os.Open(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			mp := NewMeSHParserFromReader(f)
+			_, _ = mp.ParseToSliceAndMap()
+			f.Close()
+			if err := mp.Err(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	for _, n := range []int{1, 2, 4, 8} {
+		n := n
+		b.Run(fmt.Sprintf("parallel-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				f, err := os.Open(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				mp := NewMeSHParserFromReader(f)
+				ch := mp.ParseParallel(context.Background(), n)
+				for range ch {
+				}
+				f.Close()
+				if err := mp.Err(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+/* See LICENSE file for copyright and license details. */
+
+package mesh
+
+import (
+	"sort"
+	"testing"
+)
+
+// buildTestTree builds a small MeSHNode tree under "C01" with a sibling
+// branch "C02", for use by the tree-query tests.
+func buildTestTree() *MeSHNode {
+	root := NewNode(make(map[string]*MeSHNode, 5))
+	for _, path := range []string{"C01.001", "C01.001.001", "C01.002", "C02.001"} {
+		root.Add(splitPath(path))
+	}
+	return root
+}
+
+func splitPath(path string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(path); i++ {
+		if path[i] == '.' {
+			parts = append(parts, path[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, path[start:])
+	return parts
+}
+
+func TestMeSHNodeGetChildren(t *testing.T) {
+	root := buildTestTree()
+
+	got := root.GetChildren("C01")
+	want := []string{"C01.001", "C01.002"}
+	sort.Strings(got)
+	if !equalStrings(got, want) {
+		t.Errorf("GetChildren(%q) = %v, want %v", "C01", got, want)
+	}
+
+	if got := root.GetChildren("C99"); got != nil {
+		t.Errorf("GetChildren(%q) = %v, want nil for a path that does not exist", "C99", got)
+	}
+}
+
+func TestMeSHNodeGetDescendants(t *testing.T) {
+	root := buildTestTree()
+
+	got := root.GetDescendants("C01")
+	want := []string{"C01.001", "C01.001.001", "C01.002"}
+	sort.Strings(got)
+	if !equalStrings(got, want) {
+		t.Errorf("GetDescendants(%q) = %v, want %v", "C01", got, want)
+	}
+
+	if got := root.GetDescendants("C99"); got != nil {
+		t.Errorf("GetDescendants(%q) = %v, want nil for a path that does not exist", "C99", got)
+	}
+
+	// GetSamePrefix is kept for source compatibility and must agree with
+	// GetDescendants.
+	got = root.GetSamePrefix("C01")
+	sort.Strings(got)
+	if !equalStrings(got, want) {
+		t.Errorf("GetSamePrefix(%q) = %v, want %v", "C01", got, want)
+	}
+}
+
+func TestMeSHNodeGetAncestors(t *testing.T) {
+	root := buildTestTree()
+
+	got := root.GetAncestors("C01.001.001")
+	want := []string{"C01", "C01.001"}
+	if !equalStrings(got, want) {
+		t.Errorf("GetAncestors(%q) = %v, want %v", "C01.001.001", got, want)
+	}
+
+	if got := root.GetAncestors("C01"); got != nil {
+		t.Errorf("GetAncestors(%q) = %v, want nil for a root-level path", "C01", got)
+	}
+
+	if got := root.GetAncestors("C99"); got != nil {
+		t.Errorf("GetAncestors(%q) = %v, want nil for a path that does not exist", "C99", got)
+	}
+}
+
+func TestMeSHNodeGetSiblings(t *testing.T) {
+	root := buildTestTree()
+
+	got := root.GetSiblings("C01.001")
+	want := []string{"C01.002"}
+	if !equalStrings(got, want) {
+		t.Errorf("GetSiblings(%q) = %v, want %v", "C01.001", got, want)
+	}
+
+	got = root.GetSiblings("C01")
+	want = []string{"C02"}
+	if !equalStrings(got, want) {
+		t.Errorf("GetSiblings(%q) = %v, want %v", "C01", got, want)
+	}
+
+	if got := root.GetSiblings("C99"); got != nil {
+		t.Errorf("GetSiblings(%q) = %v, want nil for a path that does not exist", "C99", got)
+	}
+}
+
+func TestMeSHNodeDepth(t *testing.T) {
+	root := buildTestTree()
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"C01", 1},
+		{"C01.001", 2},
+		{"C01.001.001", 3},
+		{"C99", -1},
+	}
+	for _, tt := range tests {
+		if got := root.Depth(tt.path); got != tt.want {
+			t.Errorf("Depth(%q) = %d, want %d", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMeSHNodeWalk(t *testing.T) {
+	root := buildTestTree()
+
+	var visited []string
+	if err := root.Walk(func(path string) error {
+		visited = append(visited, path)
+		return nil
+	}); err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+
+	want := []string{"C01", "C01.001", "C01.001.001", "C01.002", "C02", "C02.001"}
+	sort.Strings(visited)
+	if !equalStrings(visited, want) {
+		t.Errorf("Walk visited %v, want %v", visited, want)
+	}
+}
+
+func TestMeSHNodeWalkStopsOnError(t *testing.T) {
+	root := buildTestTree()
+	errStop := errTestStop{}
+
+	count := 0
+	err := root.Walk(func(path string) error {
+		count++
+		return errStop
+	})
+	if err != errStop {
+		t.Fatalf("Walk returned %v, want errStop", err)
+	}
+	if count != 1 {
+		t.Fatalf("Walk called fn %d times before stopping, want 1", count)
+	}
+}
+
+type errTestStop struct{}
+
+func (errTestStop) Error() string { return "stop" }
+
+func TestMeSHTreeNumbersForUIAndMH(t *testing.T) {
+	records := MeSHRecordsMap{
+		"C01.001": {UI: "D000001", MH: "Disease Foo", MN: []string{"C01.001"}},
+		"C02.001": {UI: "D000002", MH: "Disease Bar", MN: []string{"C02.001", "C02.002"}},
+	}
+	tree := buildTestTree()
+	m := NewMeSH(tree, records)
+
+	got := m.TreeNumbersForUI("D000002")
+	want := []string{"C02.001", "C02.002"}
+	if !equalStrings(got, want) {
+		t.Errorf("TreeNumbersForUI(%q) = %v, want %v", "D000002", got, want)
+	}
+	if got := m.TreeNumbersForUI("D999999"); got != nil {
+		t.Errorf("TreeNumbersForUI(%q) = %v, want nil for an unknown UI", "D999999", got)
+	}
+
+	got = m.TreeNumbersForMH("Disease Foo")
+	want = []string{"C01.001"}
+	if !equalStrings(got, want) {
+		t.Errorf("TreeNumbersForMH(%q) = %v, want %v", "Disease Foo", got, want)
+	}
+	if got := m.TreeNumbersForMH("Disease Nonexistent"); got != nil {
+		t.Errorf("TreeNumbersForMH(%q) = %v, want nil for an unknown heading", "Disease Nonexistent", got)
+	}
+}
@@ -0,0 +1,505 @@
+/* See LICENSE file for copyright and license details. */
+
+package mesh
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// MeSHXMLParser can be used to parse the descriptor, qualifier and
+// supplemental XML distributions that the NLM ships alongside the ASCII
+// record format (desc*.xml, qual*.xml and supp*.xml). Like MeSHParser,
+// only one of its Parse* methods should be called per parser instance.
+//
+// A parse error is kept on the parser and can be retrieved with Err once
+// the channel returned by one of the Parse* methods has been closed.
+type MeSHXMLParser struct {
+	meshinput         io.Reader
+	quotrep           *strings.Replacer
+	meshrecords       MeSHRecordsMap
+	meshqualifiers    MeSHQualifiersMap
+	meshsupplementals MeSHSupplementalsMap
+	err               error
+}
+
+// MeSHQualifier holds a single record out of a qual*.xml file.
+type MeSHQualifier struct {
+	MH      string
+	MN      []string
+	Entries map[string]bool
+	UI      string
+}
+
+type MeSHQualifiersMap map[string]*MeSHQualifier
+
+// MeSHSupplemental holds a single record out of a supp*.xml file.
+type MeSHSupplemental struct {
+	MH      string
+	Entries map[string]bool
+	UI      string
+}
+
+type MeSHSupplementalsMap map[string]*MeSHSupplemental
+
+// NewMeSHXMLParser returns a new MeSHXMLParser that reads one of the
+// desc*.xml, qual*.xml or supp*.xml files from r.
+func NewMeSHXMLParser(r io.Reader) *MeSHXMLParser {
+	return &MeSHXMLParser{
+		meshinput:         r,
+		quotrep:           strings.NewReplacer("\"", ""),
+		meshrecords:       make(MeSHRecordsMap, 50000),
+		meshqualifiers:    make(MeSHQualifiersMap, 100),
+		meshsupplementals: make(MeSHSupplementalsMap, 20000),
+	}
+}
+
+// Err returns the first error encountered while parsing, once the
+// channel returned by one of the Parse* methods has been closed. It
+// returns nil if parsing completed successfully or hasn't finished yet.
+func (mp *MeSHXMLParser) Err() error {
+	return mp.err
+}
+
+// ParseToSliceAndMap parses a desc*.xml file into a slice of MeSHRecords
+// and also fills a map to the records and returns it. Call Err after it
+// returns to check whether parsing completed successfully.
+func (mp *MeSHXMLParser) ParseToSliceAndMap(ctx context.Context) ([]*MeSHRecord, MeSHRecordsMap) {
+	meshchan := make(chan *MeSHRecord, 1000)
+	mrslice := make([]*MeSHRecord, 0, 50000)
+
+	go mp.parseDescriptors(ctx, meshchan)
+	for mr := range meshchan {
+		mrslice = append(mrslice, mr)
+	}
+
+	return mrslice, mp.meshrecords
+}
+
+// ParseToChannel returns a channel on which pointers to the parsed
+// MeSHRecords will be sent. Call Err once the channel is closed to check
+// whether parsing completed successfully.
+func (mp *MeSHXMLParser) ParseToChannel(ctx context.Context, meshchan chan *MeSHRecord) chan *MeSHRecord {
+	go mp.parseDescriptors(ctx, meshchan)
+
+	return meshchan
+}
+
+// ParseToChannelAndMap returns a channel on which pointers to the parsed
+// MeSHRecords will be sent. We also return the map to the MeSHRecords
+// which can only be used after the channel has been closed (because this
+// indicates that the parsing has been completed). Call Err once the
+// channel is closed to check whether parsing completed successfully.
+func (mp *MeSHXMLParser) ParseToChannelAndMap(ctx context.Context, meshchan chan *MeSHRecord) (chan *MeSHRecord, MeSHRecordsMap) {
+	go mp.parseDescriptors(ctx, meshchan)
+
+	return meshchan, mp.meshrecords
+}
+
+// ParseQualifiersToSliceAndMap parses a qual*.xml file into a slice of
+// MeSHQualifiers and also fills a map to the qualifiers and returns it.
+// Call Err after it returns to check whether parsing completed
+// successfully.
+func (mp *MeSHXMLParser) ParseQualifiersToSliceAndMap(ctx context.Context) ([]*MeSHQualifier, MeSHQualifiersMap) {
+	qualchan := make(chan *MeSHQualifier, 100)
+	mqslice := make([]*MeSHQualifier, 0, 100)
+
+	go mp.parseQualifiers(ctx, qualchan)
+	for mq := range qualchan {
+		mqslice = append(mqslice, mq)
+	}
+
+	return mqslice, mp.meshqualifiers
+}
+
+// ParseQualifiersToChannel returns a channel on which pointers to the
+// parsed MeSHQualifiers will be sent. Call Err once the channel is
+// closed to check whether parsing completed successfully.
+func (mp *MeSHXMLParser) ParseQualifiersToChannel(ctx context.Context, qualchan chan *MeSHQualifier) chan *MeSHQualifier {
+	go mp.parseQualifiers(ctx, qualchan)
+
+	return qualchan
+}
+
+// ParseQualifiersToChannelAndMap returns a channel on which pointers to
+// the parsed MeSHQualifiers will be sent, together with the map to the
+// MeSHQualifiers, which can only be used after the channel has been
+// closed. Call Err once the channel is closed to check whether parsing
+// completed successfully.
+func (mp *MeSHXMLParser) ParseQualifiersToChannelAndMap(ctx context.Context, qualchan chan *MeSHQualifier) (chan *MeSHQualifier, MeSHQualifiersMap) {
+	go mp.parseQualifiers(ctx, qualchan)
+
+	return qualchan, mp.meshqualifiers
+}
+
+// ParseSupplementalsToSliceAndMap parses a supp*.xml file into a slice of
+// MeSHSupplementals and also fills a map to the records and returns it.
+// Call Err after it returns to check whether parsing completed
+// successfully.
+func (mp *MeSHXMLParser) ParseSupplementalsToSliceAndMap(ctx context.Context) ([]*MeSHSupplemental, MeSHSupplementalsMap) {
+	suppchan := make(chan *MeSHSupplemental, 1000)
+	msslice := make([]*MeSHSupplemental, 0, 20000)
+
+	go mp.parseSupplementals(ctx, suppchan)
+	for ms := range suppchan {
+		msslice = append(msslice, ms)
+	}
+
+	return msslice, mp.meshsupplementals
+}
+
+// ParseSupplementalsToChannel returns a channel on which pointers to the
+// parsed MeSHSupplementals will be sent. Call Err once the channel is
+// closed to check whether parsing completed successfully.
+func (mp *MeSHXMLParser) ParseSupplementalsToChannel(ctx context.Context, suppchan chan *MeSHSupplemental) chan *MeSHSupplemental {
+	go mp.parseSupplementals(ctx, suppchan)
+
+	return suppchan
+}
+
+// ParseSupplementalsToChannelAndMap returns a channel on which pointers
+// to the parsed MeSHSupplementals will be sent, together with the map to
+// the MeSHSupplementals, which can only be used after the channel has
+// been closed. Call Err once the channel is closed to check whether
+// parsing completed successfully.
+func (mp *MeSHXMLParser) ParseSupplementalsToChannelAndMap(ctx context.Context, suppchan chan *MeSHSupplemental) (chan *MeSHSupplemental, MeSHSupplementalsMap) {
+	go mp.parseSupplementals(ctx, suppchan)
+
+	return suppchan, mp.meshsupplementals
+}
+
+// parseDescriptors streams a DescriptorRecordSet one DescriptorRecord at
+// a time so peak memory stays comparable to the ASCII record path.
+func (mp *MeSHXMLParser) parseDescriptors(ctx context.Context, meshchan chan *MeSHRecord) {
+	defer close(meshchan)
+
+	dec := xml.NewDecoder(mp.meshinput)
+	for {
+		select {
+		case <-ctx.Done():
+			mp.err = ctx.Err()
+			return
+		default:
+		}
+
+		tok, err := dec.Token()
+		if err != nil {
+			if err != io.EOF {
+				mp.err = err
+			}
+			return
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "DescriptorRecord" {
+			continue
+		}
+
+		record, err := mp.parseDescriptorRecord(dec)
+		if err != nil {
+			mp.err = err
+			return
+		}
+
+		for _, mn := range record.MN {
+			mp.meshrecords[mn] = record
+		}
+
+		select {
+		case meshchan <- record:
+		case <-ctx.Done():
+			mp.err = ctx.Err()
+			return
+		}
+	}
+}
+
+func (mp *MeSHXMLParser) parseDescriptorRecord(dec *xml.Decoder) (*MeSHRecord, error) {
+	record := &MeSHRecord{Entries: make(map[string]bool, 5)}
+
+	var (
+		inDescriptorName bool
+		inTreeNumberList bool
+		inConcept        bool
+		conceptPreferred bool
+		inTerm           bool
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "DescriptorUI":
+				if err := dec.DecodeElement(&record.UI, &t); err != nil {
+					return nil, err
+				}
+			case "DescriptorName":
+				inDescriptorName = true
+			case "TreeNumberList":
+				inTreeNumberList = true
+			case "TreeNumber":
+				if inTreeNumberList {
+					var tn string
+					if err := dec.DecodeElement(&tn, &t); err != nil {
+						return nil, err
+					}
+					record.MN = append(record.MN, tn)
+				}
+			case "Concept":
+				inConcept = true
+				conceptPreferred = isPreferredConcept(t)
+			case "ScopeNote":
+				if inConcept && conceptPreferred {
+					var sn string
+					if err := dec.DecodeElement(&sn, &t); err != nil {
+						return nil, err
+					}
+					record.MS = strings.TrimSpace(sn)
+				}
+			case "Term":
+				inTerm = true
+			case "String":
+				var s string
+				if err := dec.DecodeElement(&s, &t); err != nil {
+					return nil, err
+				}
+				if inDescriptorName {
+					record.MH = s
+					inDescriptorName = false
+				} else if inTerm {
+					record.Entries[mp.quotrep.Replace(reorderPersonalName(s))] = true
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "DescriptorRecord":
+				return record, nil
+			case "TreeNumberList":
+				inTreeNumberList = false
+			case "Concept":
+				inConcept = false
+				conceptPreferred = false
+			case "Term":
+				inTerm = false
+			}
+		}
+	}
+}
+
+// parseQualifiers streams a QualifierRecordSet one QualifierRecord at a
+// time.
+func (mp *MeSHXMLParser) parseQualifiers(ctx context.Context, qualchan chan *MeSHQualifier) {
+	defer close(qualchan)
+
+	dec := xml.NewDecoder(mp.meshinput)
+	for {
+		select {
+		case <-ctx.Done():
+			mp.err = ctx.Err()
+			return
+		default:
+		}
+
+		tok, err := dec.Token()
+		if err != nil {
+			if err != io.EOF {
+				mp.err = err
+			}
+			return
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "QualifierRecord" {
+			continue
+		}
+
+		qualifier, err := mp.parseQualifierRecord(dec)
+		if err != nil {
+			mp.err = err
+			return
+		}
+
+		for _, mn := range qualifier.MN {
+			mp.meshqualifiers[mn] = qualifier
+		}
+
+		select {
+		case qualchan <- qualifier:
+		case <-ctx.Done():
+			mp.err = ctx.Err()
+			return
+		}
+	}
+}
+
+func (mp *MeSHXMLParser) parseQualifierRecord(dec *xml.Decoder) (*MeSHQualifier, error) {
+	qualifier := &MeSHQualifier{Entries: make(map[string]bool, 5)}
+
+	var (
+		inQualifierName  bool
+		inTreeNumberList bool
+		inTerm           bool
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "QualifierUI":
+				if err := dec.DecodeElement(&qualifier.UI, &t); err != nil {
+					return nil, err
+				}
+			case "QualifierName":
+				inQualifierName = true
+			case "TreeNumberList":
+				inTreeNumberList = true
+			case "TreeNumber":
+				if inTreeNumberList {
+					var tn string
+					if err := dec.DecodeElement(&tn, &t); err != nil {
+						return nil, err
+					}
+					qualifier.MN = append(qualifier.MN, tn)
+				}
+			case "Term":
+				inTerm = true
+			case "String":
+				var s string
+				if err := dec.DecodeElement(&s, &t); err != nil {
+					return nil, err
+				}
+				if inQualifierName {
+					qualifier.MH = s
+					inQualifierName = false
+				} else if inTerm {
+					qualifier.Entries[mp.quotrep.Replace(reorderPersonalName(s))] = true
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "QualifierRecord":
+				return qualifier, nil
+			case "TreeNumberList":
+				inTreeNumberList = false
+			case "Term":
+				inTerm = false
+			}
+		}
+	}
+}
+
+// parseSupplementals streams a SupplementalRecordSet one
+// SupplementalRecord at a time. Supplemental records have no tree
+// numbers of their own, so they are only keyed by UI.
+func (mp *MeSHXMLParser) parseSupplementals(ctx context.Context, suppchan chan *MeSHSupplemental) {
+	defer close(suppchan)
+
+	dec := xml.NewDecoder(mp.meshinput)
+	for {
+		select {
+		case <-ctx.Done():
+			mp.err = ctx.Err()
+			return
+		default:
+		}
+
+		tok, err := dec.Token()
+		if err != nil {
+			if err != io.EOF {
+				mp.err = err
+			}
+			return
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "SupplementalRecord" {
+			continue
+		}
+
+		supplemental, err := mp.parseSupplementalRecord(dec)
+		if err != nil {
+			mp.err = err
+			return
+		}
+
+		mp.meshsupplementals[supplemental.UI] = supplemental
+
+		select {
+		case suppchan <- supplemental:
+		case <-ctx.Done():
+			mp.err = ctx.Err()
+			return
+		}
+	}
+}
+
+func (mp *MeSHXMLParser) parseSupplementalRecord(dec *xml.Decoder) (*MeSHSupplemental, error) {
+	supplemental := &MeSHSupplemental{Entries: make(map[string]bool, 5)}
+
+	var (
+		inSupplementalName bool
+		inTerm             bool
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "SupplementalRecordUI":
+				if err := dec.DecodeElement(&supplemental.UI, &t); err != nil {
+					return nil, err
+				}
+			case "SupplementalRecordName":
+				inSupplementalName = true
+			case "Term":
+				inTerm = true
+			case "String":
+				var s string
+				if err := dec.DecodeElement(&s, &t); err != nil {
+					return nil, err
+				}
+				if inSupplementalName {
+					supplemental.MH = s
+					inSupplementalName = false
+				} else if inTerm {
+					supplemental.Entries[mp.quotrep.Replace(reorderPersonalName(s))] = true
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "SupplementalRecord":
+				return supplemental, nil
+			case "Term":
+				inTerm = false
+			}
+		}
+	}
+}
+
+// isPreferredConcept reports whether a <Concept> start element is marked
+// as the preferred concept for its record (PreferredConceptYN="Y").
+func isPreferredConcept(se xml.StartElement) bool {
+	for _, attr := range se.Attr {
+		if attr.Name.Local == "PreferredConceptYN" {
+			return attr.Value == "Y"
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+/* See LICENSE file for copyright and license details. */
+
+package mesh
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const descriptorFixture = `<?xml version="1.0"?>
+<DescriptorRecordSet>
+  <DescriptorRecord DescriptorClass="1">
+    <DescriptorUI>D000001</DescriptorUI>
+    <DescriptorName>
+      <String>Disease Foo</String>
+    </DescriptorName>
+    <TreeNumberList>
+      <TreeNumber>C01.001</TreeNumber>
+      <TreeNumber>C02.001</TreeNumber>
+    </TreeNumberList>
+    <ConceptList>
+      <Concept PreferredConceptYN="Y">
+        <ScopeNote>A made-up disease.</ScopeNote>
+        <TermList>
+          <Term>
+            <String>Foo, Disease</String>
+          </Term>
+        </TermList>
+      </Concept>
+    </ConceptList>
+  </DescriptorRecord>
+</DescriptorRecordSet>
+`
+
+const qualifierFixture = `<?xml version="1.0"?>
+<QualifierRecordSet>
+  <QualifierRecord>
+    <QualifierUI>Q000001</QualifierUI>
+    <QualifierName>
+      <String>adverse effects</String>
+    </QualifierName>
+    <TreeNumberList>
+      <TreeNumber>Q000001</TreeNumber>
+    </TreeNumberList>
+    <TermList>
+      <Term>
+        <String>AE</String>
+      </Term>
+    </TermList>
+  </QualifierRecord>
+</QualifierRecordSet>
+`
+
+const supplementalFixture = `<?xml version="1.0"?>
+<SupplementalRecordSet>
+  <SupplementalRecord>
+    <SupplementalRecordUI>C000001</SupplementalRecordUI>
+    <SupplementalRecordName>
+      <String>Foo Compound</String>
+    </SupplementalRecordName>
+    <TermList>
+      <Term>
+        <String>Foo, Compound</String>
+      </Term>
+    </TermList>
+  </SupplementalRecord>
+</SupplementalRecordSet>
+`
+
+func TestMeSHXMLParserParseToSliceAndMap(t *testing.T) {
+	mp := NewMeSHXMLParser(strings.NewReader(descriptorFixture))
+	records, mrmap := mp.ParseToSliceAndMap(context.Background())
+	if err := mp.Err(); err != nil {
+		t.Fatalf("ParseToSliceAndMap: %v", err)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	record := records[0]
+	if record.UI != "D000001" {
+		t.Errorf("UI = %q, want D000001", record.UI)
+	}
+	if record.MH != "Disease Foo" {
+		t.Errorf("MH = %q, want %q", record.MH, "Disease Foo")
+	}
+	if record.MS != "A made-up disease." {
+		t.Errorf("MS = %q, want %q", record.MS, "A made-up disease.")
+	}
+	if want := []string{"C01.001", "C02.001"}; !equalStrings(record.MN, want) {
+		t.Errorf("MN = %v, want %v", record.MN, want)
+	}
+	if !record.Entries["Disease Foo"] {
+		t.Errorf("Entries = %v, want it to contain the reordered entry term", record.Entries)
+	}
+	for _, mn := range record.MN {
+		if mrmap[mn] != record {
+			t.Errorf("mrmap[%q] = %v, want %v", mn, mrmap[mn], record)
+		}
+	}
+}
+
+func TestMeSHXMLParserParseQualifiersToSliceAndMap(t *testing.T) {
+	mp := NewMeSHXMLParser(strings.NewReader(qualifierFixture))
+	qualifiers, mqmap := mp.ParseQualifiersToSliceAndMap(context.Background())
+	if err := mp.Err(); err != nil {
+		t.Fatalf("ParseQualifiersToSliceAndMap: %v", err)
+	}
+
+	if len(qualifiers) != 1 {
+		t.Fatalf("got %d qualifiers, want 1", len(qualifiers))
+	}
+	qualifier := qualifiers[0]
+	if qualifier.UI != "Q000001" {
+		t.Errorf("UI = %q, want Q000001", qualifier.UI)
+	}
+	if qualifier.MH != "adverse effects" {
+		t.Errorf("MH = %q, want %q", qualifier.MH, "adverse effects")
+	}
+	if !qualifier.Entries["AE"] {
+		t.Errorf("Entries = %v, want it to contain %q", qualifier.Entries, "AE")
+	}
+	if mqmap["Q000001"] != qualifier {
+		t.Errorf("mqmap[%q] = %v, want %v", "Q000001", mqmap["Q000001"], qualifier)
+	}
+}
+
+func TestMeSHXMLParserParseSupplementalsToSliceAndMap(t *testing.T) {
+	mp := NewMeSHXMLParser(strings.NewReader(supplementalFixture))
+	supplementals, msmap := mp.ParseSupplementalsToSliceAndMap(context.Background())
+	if err := mp.Err(); err != nil {
+		t.Fatalf("ParseSupplementalsToSliceAndMap: %v", err)
+	}
+
+	if len(supplementals) != 1 {
+		t.Fatalf("got %d supplementals, want 1", len(supplementals))
+	}
+	supplemental := supplementals[0]
+	if supplemental.UI != "C000001" {
+		t.Errorf("UI = %q, want C000001", supplemental.UI)
+	}
+	if supplemental.MH != "Foo Compound" {
+		t.Errorf("MH = %q, want %q", supplemental.MH, "Foo Compound")
+	}
+	if !supplemental.Entries["Compound Foo"] {
+		t.Errorf("Entries = %v, want it to contain the reordered entry term", supplemental.Entries)
+	}
+	if msmap["C000001"] != supplemental {
+		t.Errorf("msmap[%q] = %v, want %v", "C000001", msmap["C000001"], supplemental)
+	}
+}
+
+func TestMeSHXMLParserTruncatedInputSetsErr(t *testing.T) {
+	truncated := descriptorFixture[:strings.Index(descriptorFixture, "<TreeNumberList>")]
+
+	mp := NewMeSHXMLParser(strings.NewReader(truncated))
+	records, _ := mp.ParseToSliceAndMap(context.Background())
+	if len(records) != 0 {
+		t.Fatalf("got %d records from truncated input, want 0", len(records))
+	}
+	if mp.Err() == nil {
+		t.Fatal("Err() = nil, want a parse error for truncated XML")
+	}
+}
+
+func equalStrings(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}